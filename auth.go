@@ -0,0 +1,30 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// bearerAuthMiddleware protects mutating endpoints with a single shared
+// bearer token, configured via the SCHEDULER_AUTH_TOKEN env var. If the env
+// var is unset, requests are refused rather than silently left open.
+func bearerAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := os.Getenv("SCHEDULER_AUTH_TOKEN")
+		if token == "" {
+			log.Printf("SCHEDULER_AUTH_TOKEN is not set; refusing %s %s", r.Method, r.URL.Path)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if got == "" || got != token {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// dockerClient is the subset of *client.Client that DockerExecutor.Run needs,
+// narrowed so tests can supply a fake.
+type dockerClient interface {
+	ImagePull(ctx context.Context, refStr string, options types.ImagePullOptions) (io.ReadCloser, error)
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error)
+	ContainerStart(ctx context.Context, containerID string, options types.ContainerStartOptions) error
+	ContainerWait(ctx context.Context, containerID string, condition container.WaitCondition) (<-chan container.WaitResponse, <-chan error)
+	ContainerLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error)
+	ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error
+
+	// Ping is used only by the readiness check, not by Run.
+	Ping(ctx context.Context) (types.Ping, error)
+}
+
+// DockerExecutor runs jobs as containers against a local Docker Engine, for
+// developing and testing schedules without touching AWS or a cluster.
+type DockerExecutor struct {
+	Client dockerClient
+}
+
+// NewDockerExecutor builds a DockerExecutor talking to the Docker Engine API
+// over the environment's default connection (DOCKER_HOST, or the local
+// socket). Errors connecting are deferred to Run, since not every deploy
+// uses this backend.
+func NewDockerExecutor(cf ConfigFile) *DockerExecutor {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return &DockerExecutor{}
+	}
+	return &DockerExecutor{Client: cli}
+}
+
+// Run pulls the job's image, runs it with its command and environment, waits
+// for it to exit, and returns its exit code and combined logs.
+func (e *DockerExecutor) Run(ctx context.Context, j Job) (*RunResult, error) {
+	if e.Client == nil {
+		return nil, fmt.Errorf("docker executor: no client available")
+	}
+
+	reader, err := e.Client.ImagePull(ctx, j.Image, types.ImagePullOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("pull image: %v", err)
+	}
+	defer reader.Close()
+	if _, err := io.Copy(ioutil.Discard, reader); err != nil {
+		return nil, fmt.Errorf("pull image: %v", err)
+	}
+
+	var env []string
+	for k, v := range j.Environment {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	created, err := e.Client.ContainerCreate(ctx, &container.Config{
+		Image: j.Image,
+		Cmd:   j.Command,
+		Env:   env,
+	}, nil, nil, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("create container: %v", err)
+	}
+	defer e.Client.ContainerRemove(ctx, created.ID, types.ContainerRemoveOptions{Force: true})
+
+	if err := e.Client.ContainerStart(ctx, created.ID, types.ContainerStartOptions{}); err != nil {
+		return nil, fmt.Errorf("start container: %v", err)
+	}
+
+	statusCh, errCh := e.Client.ContainerWait(ctx, created.ID, container.WaitConditionNotRunning)
+	var exitCode int64
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return nil, fmt.Errorf("wait container: %v", err)
+		}
+	case status := <-statusCh:
+		exitCode = status.StatusCode
+	}
+
+	logs, err := e.Client.ContainerLogs(ctx, created.ID, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return nil, fmt.Errorf("container logs: %v", err)
+	}
+	defer logs.Close()
+
+	// The container was created without a TTY, so stdout and stderr arrive
+	// multiplexed on the one stream; demux both into the same buffer rather
+	// than copying the raw frame headers into the log text.
+	var buf bytes.Buffer
+	if _, err := stdcopy.StdCopy(&buf, &buf, logs); err != nil {
+		return nil, fmt.Errorf("container logs: %v", err)
+	}
+
+	return &RunResult{ExitCode: exitCode, Logs: buf.String()}, nil
+}
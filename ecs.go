@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// pollInterval is how often we check DescribeTasks while waiting for a task
+// to stop. A var, not a const, so tests can shrink it.
+var pollInterval = 5 * time.Second
+
+// ECSExecutor runs jobs as AWS ECS tasks. It is the original, and still
+// default, Executor backend.
+type ECSExecutor struct {
+	Cluster        string
+	LaunchType     string
+	Subnets        []string
+	SecurityGroups []string
+}
+
+// NewECSExecutor builds an ECSExecutor from a ConfigFile. Cluster and
+// LaunchType fall back to environment variables when left unset; Subnets and
+// SecurityGroups have no environment fallback.
+func NewECSExecutor(cf ConfigFile) *ECSExecutor {
+	return &ECSExecutor{
+		Cluster:        ecsSetting(cf.Cluster, "ECS_CLUSTER"),
+		LaunchType:     ecsSetting(cf.LaunchType, "ECS_LAUNCH_TYPE"),
+		Subnets:        cf.Subnets,
+		SecurityGroups: cf.SecurityGroups,
+	}
+}
+
+// ecsSetting returns the ConfigFile value if set, else falls back to the
+// given environment variable.
+func ecsSetting(fromConfig, envVar string) string {
+	if fromConfig != "" {
+		return fromConfig
+	}
+	return os.Getenv(envVar)
+}
+
+// buildTaskDefinitionInput resolves a Job into the RegisterTaskDefinitionInput
+// ECS would run it under, without submitting anything. This is also what
+// backs the /jobs/{name}/dry-run endpoint.
+func (e *ECSExecutor) buildTaskDefinitionInput(j Job) *ecs.RegisterTaskDefinitionInput {
+	containerDef := &ecs.ContainerDefinition{
+		Essential:         aws.Bool(true),
+		Image:             aws.String(j.Image),
+		MemoryReservation: aws.Int64(1024),
+		Name:              j.Name(),
+	}
+
+	if len(j.Command) > 0 {
+		cmd := []*string{}
+		for _, i := range j.Command {
+			cmd = append(cmd, aws.String(i))
+		}
+
+		containerDef.Command = cmd
+	}
+
+	if len(j.Environment) > 0 {
+		pairs := []*ecs.KeyValuePair{}
+		for k, v := range j.Environment {
+			pairs = append(pairs, &ecs.KeyValuePair{
+				Name:  aws.String(k),
+				Value: aws.String(v),
+			})
+		}
+		containerDef.Environment = pairs
+	}
+
+	return &ecs.RegisterTaskDefinitionInput{
+		ContainerDefinitions: []*ecs.ContainerDefinition{containerDef},
+		Family:               j.Name(),
+		TaskRoleArn:          aws.String(""),
+	}
+}
+
+// Run takes the docker image and the command, builds a task definition,
+// submits it to ECS, waits for it to finish, and returns its exit status and
+// logs.
+func (e *ECSExecutor) Run(ctx context.Context, j Job) (*RunResult, error) {
+	svc := ecs.New(session.New())
+
+	def, err := svc.RegisterTaskDefinition(e.buildTaskDefinitionInput(j))
+	if err != nil {
+		return nil, fmt.Errorf("register task definition: %v", err)
+	}
+
+	runInput := &ecs.RunTaskInput{
+		Cluster:        aws.String(e.Cluster),
+		TaskDefinition: def.TaskDefinition.TaskDefinitionArn,
+		Count:          aws.Int64(1),
+	}
+
+	if e.LaunchType != "" {
+		runInput.LaunchType = aws.String(e.LaunchType)
+	}
+
+	if len(e.Subnets) > 0 {
+		runInput.NetworkConfiguration = &ecs.NetworkConfiguration{
+			AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+				Subnets:        aws.StringSlice(e.Subnets),
+				SecurityGroups: aws.StringSlice(e.SecurityGroups),
+				AssignPublicIp: aws.String(ecs.AssignPublicIpDisabled),
+			},
+		}
+	}
+
+	ran, err := svc.RunTask(runInput)
+	if err != nil {
+		return nil, fmt.Errorf("run task: %v", err)
+	}
+	if len(ran.Tasks) == 0 {
+		return nil, fmt.Errorf("run task: no task returned for %q", j.RawName)
+	}
+	taskArn := ran.Tasks[0].TaskArn
+
+	task, err := waitForTask(ctx, svc, e.Cluster, taskArn)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RunResult{}
+	if task.StoppedReason != nil {
+		result.StoppedReason = *task.StoppedReason
+	}
+	if len(task.Containers) > 0 && task.Containers[0].ExitCode != nil {
+		result.ExitCode = *task.Containers[0].ExitCode
+	}
+
+	logs, err := fetchLogs(def.TaskDefinition, j.Name(), taskID(task.TaskArn))
+	if err != nil {
+		log.Printf("Error fetching logs for %q: %+v", j.RawName, err)
+	} else {
+		result.Logs = logs
+	}
+
+	return result, nil
+}
+
+// ecsTaskDescriber is the subset of the ECS client that waitForTask needs,
+// narrowed so tests can supply a fake.
+type ecsTaskDescriber interface {
+	DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error)
+}
+
+// waitForTask polls DescribeTasks until the task reaches STOPPED or ctx is
+// canceled.
+func waitForTask(ctx context.Context, svc ecsTaskDescriber, cluster string, taskArn *string) (*ecs.Task, error) {
+	for {
+		out, err := svc.DescribeTasks(&ecs.DescribeTasksInput{
+			Cluster: aws.String(cluster),
+			Tasks:   []*string{taskArn},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("describe tasks: %v", err)
+		}
+		if len(out.Tasks) == 0 {
+			return nil, fmt.Errorf("describe tasks: task %q not found", *taskArn)
+		}
+
+		task := out.Tasks[0]
+		if task.LastStatus != nil && *task.LastStatus == ecs.DesiredStatusStopped {
+			return task, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// taskID extracts the task ID off the end of a task ARN (either the
+// "task/cluster-name/task-id" or legacy "task/task-id" form), for building
+// the awslogs stream name.
+func taskID(taskArn *string) string {
+	if taskArn == nil {
+		return ""
+	}
+	parts := strings.Split(*taskArn, "/")
+	return parts[len(parts)-1]
+}
+
+// fetchLogs pulls the container's output from CloudWatch Logs, if the task
+// definition's container uses the awslogs driver. The awslogs stream name is
+// "<awslogs-stream-prefix>/<container-name>/<task-id>".
+func fetchLogs(def *ecs.TaskDefinition, containerName *string, taskID string) (string, error) {
+	if len(def.ContainerDefinitions) == 0 {
+		return "", nil
+	}
+
+	logConf := def.ContainerDefinitions[0].LogConfiguration
+	if logConf == nil || logConf.LogDriver == nil || *logConf.LogDriver != "awslogs" {
+		return "", nil
+	}
+
+	group := logConf.Options["awslogs-group"]
+	streamPrefix := logConf.Options["awslogs-stream-prefix"]
+	if group == nil || streamPrefix == nil {
+		return "", nil
+	}
+
+	stream := fmt.Sprintf("%s/%s/%s", *streamPrefix, strings.TrimPrefix(*containerName, "/"), taskID)
+
+	svc := cloudwatchlogs.New(session.New())
+	out, err := svc.GetLogEvents(&cloudwatchlogs.GetLogEventsInput{
+		LogGroupName:  group,
+		LogStreamName: aws.String(stream),
+	})
+	if err != nil {
+		return "", fmt.Errorf("get log events: %v", err)
+	}
+
+	var sb strings.Builder
+	for _, event := range out.Events {
+		if event.Message != nil {
+			sb.WriteString(*event.Message)
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// Backend names a pluggable Job execution target. A Job picks one via its
+// Backend field, falling back to ConfigFile.DefaultBackend, and then to
+// BackendECS to match this scheduler's original, ECS-only behavior.
+const (
+	BackendECS        = "ecs"
+	BackendKubernetes = "kubernetes"
+	BackendDocker     = "docker"
+)
+
+// RunResult is what an Executor invocation produced: how the job's container
+// ended and whatever logs it wrote, if any.
+type RunResult struct {
+	ExitCode      int64
+	StoppedReason string
+	Logs          string
+}
+
+// Executor runs a single Job to completion and reports how it went. Each
+// backend (ECS, Kubernetes, local Docker) implements this so the scheduler
+// isn't hardwired to any one of them.
+type Executor interface {
+	Run(ctx context.Context, j Job) (*RunResult, error)
+}
+
+// NewExecutors builds one Executor per backend from the given config.
+func NewExecutors(cf ConfigFile) map[string]Executor {
+	return map[string]Executor{
+		BackendECS:        NewECSExecutor(cf),
+		BackendKubernetes: NewKubernetesExecutor(cf),
+		BackendDocker:     NewDockerExecutor(cf),
+	}
+}
+
+// backendFor returns the backend a job should run under.
+func backendFor(cf ConfigFile, j Job) string {
+	if j.Backend != "" {
+		return j.Backend
+	}
+	if cf.DefaultBackend != "" {
+		return cf.DefaultBackend
+	}
+	return BackendECS
+}
+
+// resolveExecutor picks the Executor a job should run under out of a set
+// built by NewExecutors.
+func resolveExecutor(executors map[string]Executor, cf ConfigFile, j Job) (Executor, error) {
+	name := backendFor(cf, j)
+	ex, ok := executors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q for job %q", name, j.RawName)
+	}
+	return ex, nil
+}
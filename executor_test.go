@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/pkg/stdcopy"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeExecutor is a stand-in Executor for exercising the scheduler and the
+// backend-selection logic without talking to ECS, Kubernetes, or Docker.
+type fakeExecutor struct {
+	result *RunResult
+	err    error
+	calls  []Job
+}
+
+func (f *fakeExecutor) Run(ctx context.Context, j Job) (*RunResult, error) {
+	f.calls = append(f.calls, j)
+	return f.result, f.err
+}
+
+func TestBackendFor(t *testing.T) {
+	cases := []struct {
+		name string
+		cf   ConfigFile
+		job  Job
+		want string
+	}{
+		{"job backend wins", ConfigFile{DefaultBackend: BackendKubernetes}, Job{Backend: BackendDocker}, BackendDocker},
+		{"falls back to default", ConfigFile{DefaultBackend: BackendKubernetes}, Job{}, BackendKubernetes},
+		{"falls back to ecs", ConfigFile{}, Job{}, BackendECS},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := backendFor(c.cf, c.job)
+			if got != c.want {
+				t.Errorf("backendFor() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestResolveExecutor(t *testing.T) {
+	ecsFake := &fakeExecutor{}
+	dockerFake := &fakeExecutor{}
+	executors := map[string]Executor{
+		BackendECS:    ecsFake,
+		BackendDocker: dockerFake,
+	}
+
+	ex, err := resolveExecutor(executors, ConfigFile{}, Job{RawName: "nightly"})
+	if err != nil {
+		t.Fatalf("resolveExecutor() error = %v", err)
+	}
+	if ex != ecsFake {
+		t.Errorf("resolveExecutor() picked the wrong default backend")
+	}
+
+	ex, err = resolveExecutor(executors, ConfigFile{}, Job{RawName: "dev-job", Backend: BackendDocker})
+	if err != nil {
+		t.Fatalf("resolveExecutor() error = %v", err)
+	}
+	if ex != dockerFake {
+		t.Errorf("resolveExecutor() did not honor the job's Backend")
+	}
+
+	if _, err := resolveExecutor(executors, ConfigFile{}, Job{Backend: BackendKubernetes}); err == nil {
+		t.Errorf("resolveExecutor() expected an error for an unconfigured backend")
+	}
+}
+
+func TestSchedulerRunJobUsesResolvedExecutor(t *testing.T) {
+	fake := &fakeExecutor{result: &RunResult{ExitCode: 0}}
+	cf := ConfigFile{Jobs: []Job{{RawName: "nightly", CronRule: "@yearly"}}}
+
+	s := NewScheduler(cf)
+	s.executors = map[string]Executor{BackendECS: fake}
+
+	s.runJob(cf.Jobs[0], "test-run")
+
+	if len(fake.calls) != 1 {
+		t.Fatalf("expected the job to run once, got %d calls", len(fake.calls))
+	}
+
+	runs, ok := s.Runs("nightly")
+	if !ok || len(runs) != 1 {
+		t.Fatalf("expected one recorded run, got %d (ok=%v)", len(runs), ok)
+	}
+	if runs[0].Error != "" {
+		t.Errorf("unexpected run error: %q", runs[0].Error)
+	}
+}
+
+// fakeECSTaskDescriber is a stand-in ecsTaskDescriber that returns a queued
+// sequence of DescribeTasks responses, so waitForTask's polling loop can be
+// exercised without talking to AWS.
+type fakeECSTaskDescriber struct {
+	responses []*ecs.DescribeTasksOutput
+	calls     int
+}
+
+func (f *fakeECSTaskDescriber) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	out := f.responses[f.calls]
+	if f.calls < len(f.responses)-1 {
+		f.calls++
+	}
+	return out, nil
+}
+
+func TestWaitForTaskPollsUntilStopped(t *testing.T) {
+	orig := pollInterval
+	pollInterval = time.Millisecond
+	defer func() { pollInterval = orig }()
+
+	taskArn := aws.String("arn:aws:ecs:task/1")
+	running := &ecs.DescribeTasksOutput{Tasks: []*ecs.Task{{TaskArn: taskArn, LastStatus: aws.String("RUNNING")}}}
+	stopped := &ecs.DescribeTasksOutput{Tasks: []*ecs.Task{{TaskArn: taskArn, LastStatus: aws.String(ecs.DesiredStatusStopped)}}}
+
+	fake := &fakeECSTaskDescriber{responses: []*ecs.DescribeTasksOutput{running, stopped}}
+
+	task, err := waitForTask(context.Background(), fake, "default", taskArn)
+	if err != nil {
+		t.Fatalf("waitForTask() error = %v", err)
+	}
+	if task.LastStatus == nil || *task.LastStatus != ecs.DesiredStatusStopped {
+		t.Errorf("waitForTask() returned task with status %v, want %q", task.LastStatus, ecs.DesiredStatusStopped)
+	}
+	if fake.calls != 1 {
+		t.Errorf("expected waitForTask to poll twice, got %d", fake.calls+1)
+	}
+}
+
+func TestWaitForTaskCanceled(t *testing.T) {
+	orig := pollInterval
+	pollInterval = time.Minute
+	defer func() { pollInterval = orig }()
+
+	taskArn := aws.String("arn:aws:ecs:task/1")
+	running := &ecs.DescribeTasksOutput{Tasks: []*ecs.Task{{TaskArn: taskArn, LastStatus: aws.String("RUNNING")}}}
+	fake := &fakeECSTaskDescriber{responses: []*ecs.DescribeTasksOutput{running}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := waitForTask(ctx, fake, "default", taskArn); err == nil {
+		t.Errorf("waitForTask() expected an error when ctx is already canceled")
+	}
+}
+
+// fakeKubernetesJobGetter is a stand-in kubernetesJobGetter that returns a
+// queued sequence of Job responses, so waitForKubernetesJob's polling loop
+// can be exercised without talking to a real API server.
+type fakeKubernetesJobGetter struct {
+	responses []*batchv1.Job
+	calls     int
+}
+
+func (f *fakeKubernetesJobGetter) Get(ctx context.Context, name string, opts metav1.GetOptions) (*batchv1.Job, error) {
+	out := f.responses[f.calls]
+	if f.calls < len(f.responses)-1 {
+		f.calls++
+	}
+	return out, nil
+}
+
+func TestWaitForKubernetesJobSucceeds(t *testing.T) {
+	orig := pollInterval
+	pollInterval = time.Millisecond
+	defer func() { pollInterval = orig }()
+
+	running := &batchv1.Job{}
+	succeeded := &batchv1.Job{Status: batchv1.JobStatus{Succeeded: 1}}
+	fake := &fakeKubernetesJobGetter{responses: []*batchv1.Job{running, succeeded}}
+
+	result, err := waitForKubernetesJob(context.Background(), fake, "nightly-abcde")
+	if err != nil {
+		t.Fatalf("waitForKubernetesJob() error = %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Errorf("waitForKubernetesJob() ExitCode = %d, want 0", result.ExitCode)
+	}
+}
+
+func TestWaitForKubernetesJobFails(t *testing.T) {
+	orig := pollInterval
+	pollInterval = time.Millisecond
+	defer func() { pollInterval = orig }()
+
+	failed := &batchv1.Job{Status: batchv1.JobStatus{Failed: 1}}
+	fake := &fakeKubernetesJobGetter{responses: []*batchv1.Job{failed}}
+
+	result, err := waitForKubernetesJob(context.Background(), fake, "nightly-abcde")
+	if err != nil {
+		t.Fatalf("waitForKubernetesJob() error = %v", err)
+	}
+	if result.ExitCode == 0 {
+		t.Errorf("waitForKubernetesJob() expected a nonzero exit code for a failed job")
+	}
+}
+
+// fakeDockerClient is a stand-in dockerClient that lets a test script the
+// outcome of each call DockerExecutor.Run makes, without a real Docker
+// daemon.
+type fakeDockerClient struct {
+	pullErr    error
+	createErr  error
+	startErr   error
+	waitStatus int64
+	waitErr    error
+	logs       []byte
+	removedID  string
+}
+
+func (f *fakeDockerClient) ImagePull(ctx context.Context, refStr string, options types.ImagePullOptions) (io.ReadCloser, error) {
+	if f.pullErr != nil {
+		return nil, f.pullErr
+	}
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (f *fakeDockerClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+	if f.createErr != nil {
+		return container.CreateResponse{}, f.createErr
+	}
+	return container.CreateResponse{ID: "fake-id"}, nil
+}
+
+func (f *fakeDockerClient) ContainerStart(ctx context.Context, containerID string, options types.ContainerStartOptions) error {
+	return f.startErr
+}
+
+func (f *fakeDockerClient) ContainerWait(ctx context.Context, containerID string, condition container.WaitCondition) (<-chan container.WaitResponse, <-chan error) {
+	statusCh := make(chan container.WaitResponse, 1)
+	errCh := make(chan error, 1)
+	if f.waitErr != nil {
+		errCh <- f.waitErr
+	} else {
+		statusCh <- container.WaitResponse{StatusCode: f.waitStatus}
+	}
+	return statusCh, errCh
+}
+
+func (f *fakeDockerClient) ContainerLogs(ctx context.Context, containerID string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(f.logs)), nil
+}
+
+func (f *fakeDockerClient) ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error {
+	f.removedID = containerID
+	return nil
+}
+
+func (f *fakeDockerClient) Ping(ctx context.Context) (types.Ping, error) {
+	return types.Ping{}, nil
+}
+
+func TestDockerExecutorRun(t *testing.T) {
+	var buf bytes.Buffer
+	w := stdcopy.NewStdWriter(&buf, stdcopy.Stdout)
+	if _, err := w.Write([]byte("hello from the container\n")); err != nil {
+		t.Fatalf("writing fake stdcopy frame: %v", err)
+	}
+
+	fake := &fakeDockerClient{logs: buf.Bytes()}
+	e := &DockerExecutor{Client: fake}
+
+	result, err := e.Run(context.Background(), Job{RawName: "dev-job", Image: "alpine"})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Errorf("Run() ExitCode = %d, want 0", result.ExitCode)
+	}
+	if result.Logs != "hello from the container\n" {
+		t.Errorf("Run() Logs = %q, want %q", result.Logs, "hello from the container\n")
+	}
+	if fake.removedID != "fake-id" {
+		t.Errorf("Run() did not remove the container it created")
+	}
+}
+
+func TestDockerExecutorRunNonZeroExit(t *testing.T) {
+	fake := &fakeDockerClient{waitStatus: 1}
+	e := &DockerExecutor{Client: fake}
+
+	result, err := e.Run(context.Background(), Job{RawName: "dev-job", Image: "alpine"})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.ExitCode != 1 {
+		t.Errorf("Run() ExitCode = %d, want 1", result.ExitCode)
+	}
+}
+
+func TestDockerExecutorRunNoClient(t *testing.T) {
+	e := &DockerExecutor{}
+	if _, err := e.Run(context.Background(), Job{}); err == nil {
+		t.Error("Run() expected an error when no client is available")
+	}
+}
+
+func TestSchedulerRunJobRecordsExecutorError(t *testing.T) {
+	fake := &fakeExecutor{err: errors.New("boom")}
+	cf := ConfigFile{Jobs: []Job{{RawName: "nightly", CronRule: "@yearly"}}}
+
+	s := NewScheduler(cf)
+	s.executors = map[string]Executor{BackendECS: fake}
+
+	s.runJob(cf.Jobs[0], "test-run")
+
+	runs, ok := s.Runs("nightly")
+	if !ok || len(runs) != 1 {
+		t.Fatalf("expected one recorded run, got %d (ok=%v)", len(runs), ok)
+	}
+	if runs[0].Error == "" {
+		t.Errorf("expected the executor error to be recorded")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/robfig/cron"
+)
+
+// checkTimeout bounds how long any single Checker gets to run before it's
+// counted as failed.
+const checkTimeout = 5 * time.Second
+
+// CheckResult is the outcome of a single Checker invocation.
+type CheckResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"` // "ok" or "error"
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ReadyCheckResponse is the body of GET /_readycheck.json: the aggregate
+// result plus each sub-check that contributed to it.
+type ReadyCheckResponse struct {
+	Ready  bool          `json:"ready"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// Checker is a single readiness sub-check that can be registered with a
+// HealthCheck. Implementations should respect ctx's deadline.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// HealthCheck aggregates a set of Checkers into a single readiness report.
+type HealthCheck struct {
+	checkers []Checker
+}
+
+// NewHealthCheck builds a HealthCheck from a set of Checkers.
+func NewHealthCheck(checkers ...Checker) *HealthCheck {
+	return &HealthCheck{checkers: checkers}
+}
+
+// Run executes every registered Checker and reports whether they all passed.
+func (h *HealthCheck) Run(ctx context.Context) ReadyCheckResponse {
+	resp := ReadyCheckResponse{Ready: true, Checks: make([]CheckResult, 0, len(h.checkers))}
+
+	for _, checker := range h.checkers {
+		cctx, cancel := context.WithTimeout(ctx, checkTimeout)
+		start := time.Now()
+		err := checker.Check(cctx)
+		cancel()
+
+		res := CheckResult{Name: checker.Name(), Status: "ok", Latency: time.Since(start).String()}
+		if err != nil {
+			res.Status = "error"
+			res.Error = err.Error()
+			resp.Ready = false
+		}
+		resp.Checks = append(resp.Checks, res)
+	}
+
+	return resp
+}
+
+// configChecker verifies the config file parses and every job's cron rule is
+// valid.
+type configChecker struct{}
+
+func (configChecker) Name() string { return "config" }
+
+func (configChecker) Check(ctx context.Context) error {
+	cf, err := GetConfig()
+	if err != nil {
+		return fmt.Errorf("get config: %v", err)
+	}
+
+	for _, j := range cf.Jobs {
+		if _, err := cron.Parse(j.CronRule); err != nil {
+			return fmt.Errorf("invalid cron rule for job %q: %v", j.RawName, err)
+		}
+	}
+	return nil
+}
+
+// awsChecker verifies AWS credentials are usable by calling STS
+// GetCallerIdentity. It's skipped entirely if no configured job actually
+// resolves to the ECS backend, so a Docker- or Kubernetes-only deploy with
+// no AWS credentials doesn't permanently fail readiness.
+type awsChecker struct {
+	scheduler *Scheduler
+}
+
+func (awsChecker) Name() string { return "aws" }
+
+func (c awsChecker) Check(ctx context.Context) error {
+	if !c.scheduler.UsesBackend(BackendECS) {
+		return nil
+	}
+
+	svc := sts.New(session.New())
+	if _, err := svc.GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{}); err != nil {
+		return fmt.Errorf("sts get-caller-identity: %v", err)
+	}
+	return nil
+}
+
+// schedulerTickChecker verifies the scheduler's cron loop is still alive by
+// checking it ticked recently enough relative to its shortest job interval.
+type schedulerTickChecker struct {
+	scheduler *Scheduler
+}
+
+func (schedulerTickChecker) Name() string { return "scheduler" }
+
+func (c schedulerTickChecker) Check(ctx context.Context) error {
+	lastTick, shortestInterval := c.scheduler.Heartbeat()
+	if lastTick.IsZero() {
+		return fmt.Errorf("scheduler has not ticked yet")
+	}
+
+	if age := time.Since(lastTick); age > 2*shortestInterval {
+		return fmt.Errorf("last tick was %s ago, expected within %s", age, 2*shortestInterval)
+	}
+	return nil
+}
+
+// dockerChecker verifies the configured Docker backend can reach its daemon.
+// It looks up the current Docker executor on every Check (rather than
+// capturing one at startup) so a Reload that changes the backend set is
+// picked up, and skips entirely if no configured job resolves to the Docker
+// backend or that backend has no client.
+type dockerChecker struct {
+	scheduler *Scheduler
+}
+
+func (dockerChecker) Name() string { return "docker" }
+
+func (c dockerChecker) Check(ctx context.Context) error {
+	if !c.scheduler.UsesBackend(BackendDocker) {
+		return nil
+	}
+
+	executor, ok := c.scheduler.Executors()[BackendDocker].(*DockerExecutor)
+	if !ok || executor.Client == nil {
+		return nil
+	}
+	if _, err := executor.Client.Ping(ctx); err != nil {
+		return fmt.Errorf("docker ping: %v", err)
+	}
+	return nil
+}
+
+// kubernetesChecker verifies the configured Kubernetes backend can reach the
+// API server. It looks up the current Kubernetes executor on every Check
+// (rather than capturing one at startup) so a Reload that changes the
+// backend set is picked up, and skips entirely if that backend has no client
+// (the common case when it isn't in use).
+type kubernetesChecker struct {
+	scheduler *Scheduler
+}
+
+func (kubernetesChecker) Name() string { return "kubernetes" }
+
+func (c kubernetesChecker) Check(ctx context.Context) error {
+	executor, ok := c.scheduler.Executors()[BackendKubernetes].(*KubernetesExecutor)
+	if !ok || executor.Clientset == nil {
+		return nil
+	}
+	if _, err := executor.Clientset.Discovery().ServerVersion(); err != nil {
+		return fmt.Errorf("kubernetes server version: %v", err)
+	}
+	return nil
+}
+
+// NewReadinessCheck builds the standard set of readiness checks for a
+// running Scheduler. Each backend-specific checker reads the Scheduler's
+// live state on every Check, so they stay correct across a Reload instead of
+// needing to be rebuilt.
+func NewReadinessCheck(s *Scheduler) *HealthCheck {
+	return NewHealthCheck(
+		configChecker{},
+		awsChecker{scheduler: s},
+		schedulerTickChecker{scheduler: s},
+		dockerChecker{scheduler: s},
+		kubernetesChecker{scheduler: s},
+	)
+}
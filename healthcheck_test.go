@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeChecker struct {
+	name string
+	err  error
+}
+
+func (f fakeChecker) Name() string                   { return f.name }
+func (f fakeChecker) Check(ctx context.Context) error { return f.err }
+
+func TestHealthCheckRunAllPass(t *testing.T) {
+	h := NewHealthCheck(fakeChecker{name: "a"}, fakeChecker{name: "b"})
+	resp := h.Run(context.Background())
+
+	if !resp.Ready {
+		t.Fatalf("expected Ready=true, got false: %+v", resp.Checks)
+	}
+	if len(resp.Checks) != 2 {
+		t.Fatalf("expected 2 checks, got %d", len(resp.Checks))
+	}
+}
+
+func TestHealthCheckRunOneFails(t *testing.T) {
+	h := NewHealthCheck(fakeChecker{name: "a"}, fakeChecker{name: "b", err: errors.New("down")})
+	resp := h.Run(context.Background())
+
+	if resp.Ready {
+		t.Fatal("expected Ready=false when a check fails")
+	}
+
+	var failed *CheckResult
+	for i := range resp.Checks {
+		if resp.Checks[i].Name == "b" {
+			failed = &resp.Checks[i]
+		}
+	}
+	if failed == nil || failed.Status != "error" || failed.Error != "down" {
+		t.Fatalf("expected check %q to report the error, got %+v", "b", failed)
+	}
+}
@@ -0,0 +1,78 @@
+// Package httpx provides a small request context and a handful of response
+// helpers so handlers don't each reimplement JSON encoding, error logging,
+// and redirect validation.
+package httpx
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Context wraps an http.ResponseWriter and *http.Request together for the
+// lifetime of a single request.
+type Context struct {
+	W http.ResponseWriter
+	R *http.Request
+}
+
+// New builds a Context for a single request.
+func New(w http.ResponseWriter, r *http.Request) *Context {
+	return &Context{W: w, R: r}
+}
+
+// JSON writes v as a JSON response with the given status code.
+func (c *Context) JSON(status int, v interface{}) {
+	c.W.Header().Set("Content-Type", "application/json")
+	c.W.WriteHeader(status)
+	if err := json.NewEncoder(c.W).Encode(v); err != nil {
+		log.Printf("httpx: error encoding JSON response for %s: %+v", c.R.URL.Path, err)
+	}
+}
+
+// Error logs err with request context and writes it as a plain-text error
+// response.
+func (c *Context) Error(status int, err error) {
+	log.Printf("httpx: %s %s: %+v", c.R.Method, c.R.URL.Path, err)
+	http.Error(c.W, err.Error(), status)
+}
+
+// Redirect sends the client to target, which may be an absolute or
+// site-relative URL. Cross-origin targets (a different host than the
+// current request) have any cookie whose name contains "session" stripped
+// from the response first, so a different origin never sees them.
+func (c *Context) Redirect(status int, target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("invalid redirect target %q: %v", target, err)
+	}
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid redirect target %q: unsupported scheme %q", target, u.Scheme)
+	}
+
+	if u.Host != "" && !strings.EqualFold(u.Host, c.R.Host) {
+		c.stripSessionCookies()
+	}
+
+	http.Redirect(c.W, c.R, target, status)
+	return nil
+}
+
+// stripSessionCookies clears any cookie on the incoming request whose name
+// contains "session", ahead of a cross-origin redirect.
+func (c *Context) stripSessionCookies() {
+	for _, ck := range c.R.Cookies() {
+		if !strings.Contains(strings.ToLower(ck.Name), "session") {
+			continue
+		}
+		http.SetCookie(c.W, &http.Cookie{
+			Name:   ck.Name,
+			Value:  "",
+			Path:   "/",
+			MaxAge: -1,
+		})
+	}
+}
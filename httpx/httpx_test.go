@@ -0,0 +1,51 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectRejectsBadScheme(t *testing.T) {
+	r := httptest.NewRequest("GET", "https://example.com/", nil)
+	w := httptest.NewRecorder()
+	c := New(w, r)
+
+	if err := c.Redirect(302, "javascript:alert(1)"); err == nil {
+		t.Fatal("expected an error for a non-http(s) redirect target")
+	}
+}
+
+func TestRedirectAllowsRelative(t *testing.T) {
+	r := httptest.NewRequest("GET", "https://example.com/", nil)
+	w := httptest.NewRecorder()
+	c := New(w, r)
+
+	if err := c.Redirect(302, "/somewhere"); err != nil {
+		t.Fatalf("Redirect() error = %v", err)
+	}
+	if got := w.Header().Get("Location"); got != "/somewhere" {
+		t.Errorf("Location header = %q, want %q", got, "/somewhere")
+	}
+}
+
+func TestRedirectStripsSessionCookiesCrossOrigin(t *testing.T) {
+	r := httptest.NewRequest("GET", "https://example.com/", nil)
+	r.AddCookie(&http.Cookie{Name: "session_id", Value: "abc"})
+	w := httptest.NewRecorder()
+	c := New(w, r)
+
+	if err := c.Redirect(302, "https://other.example.com/next"); err != nil {
+		t.Fatalf("Redirect() error = %v", err)
+	}
+
+	found := false
+	for _, ck := range w.Result().Cookies() {
+		if ck.Name == "session_id" && ck.MaxAge < 0 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected session_id cookie to be cleared on cross-origin redirect")
+	}
+}
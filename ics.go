@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// icsOccurrences is how many upcoming runs schedule.ics projects.
+const icsOccurrences = 10
+
+// renderICS renders an iCalendar feed of a job's next occurrences, so it can
+// be subscribed to from a calendar client.
+func renderICS(name string, times []time.Time) string {
+	var sb strings.Builder
+	sb.WriteString("BEGIN:VCALENDAR\r\n")
+	sb.WriteString("VERSION:2.0\r\n")
+	sb.WriteString("PRODID:-//icco/scheduler//EN\r\n")
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for i, t := range times {
+		sb.WriteString("BEGIN:VEVENT\r\n")
+		fmt.Fprintf(&sb, "UID:%s-%d@scheduler.icco.dev\r\n", name, i)
+		fmt.Fprintf(&sb, "DTSTAMP:%s\r\n", stamp)
+		fmt.Fprintf(&sb, "DTSTART:%s\r\n", t.UTC().Format("20060102T150405Z"))
+		fmt.Fprintf(&sb, "SUMMARY:%s\r\n", name)
+		sb.WriteString("END:VEVENT\r\n")
+	}
+
+	sb.WriteString("END:VCALENDAR\r\n")
+	return sb.String()
+}
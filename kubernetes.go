@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// KubernetesExecutor runs jobs as batch/v1 Jobs in a configured namespace,
+// for environments that run their workloads on Kubernetes instead of ECS.
+type KubernetesExecutor struct {
+	Namespace string
+	Clientset kubernetes.Interface
+}
+
+// NewKubernetesExecutor builds a KubernetesExecutor from a ConfigFile,
+// falling back to the in-cluster config and KUBERNETES_NAMESPACE env var.
+// Errors building the in-cluster client are deferred to Run, since not every
+// deploy uses this backend.
+func NewKubernetesExecutor(cf ConfigFile) *KubernetesExecutor {
+	e := &KubernetesExecutor{
+		Namespace: ecsSetting(cf.KubernetesNamespace, "KUBERNETES_NAMESPACE"),
+	}
+	if e.Namespace == "" {
+		e.Namespace = "default"
+	}
+
+	if cfg, err := rest.InClusterConfig(); err == nil {
+		if cs, err := kubernetes.NewForConfig(cfg); err == nil {
+			e.Clientset = cs
+		}
+	}
+
+	return e
+}
+
+// Run creates a batch/v1 Job for the given Job's image/command/env and waits
+// for it to complete.
+func (e *KubernetesExecutor) Run(ctx context.Context, j Job) (*RunResult, error) {
+	if e.Clientset == nil {
+		return nil, fmt.Errorf("kubernetes executor: no in-cluster client available")
+	}
+
+	var env []corev1.EnvVar
+	for k, v := range j.Environment {
+		env = append(env, corev1.EnvVar{Name: k, Value: v})
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("scheduled-%s-", j.RawName),
+			Namespace:    e.Namespace,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: int32Ptr(0),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:    j.RawName,
+							Image:   j.Image,
+							Command: j.Command,
+							Env:     env,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	jobs := e.Clientset.BatchV1().Jobs(e.Namespace)
+	created, err := jobs.Create(ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("create job: %v", err)
+	}
+
+	return waitForKubernetesJob(ctx, jobs, created.Name)
+}
+
+// kubernetesJobGetter is the subset of the batch/v1 Jobs client that
+// waitForKubernetesJob needs, narrowed so tests can supply a fake.
+type kubernetesJobGetter interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*batchv1.Job, error)
+}
+
+// waitForKubernetesJob polls the Job's status until it reports completion,
+// failure, or ctx is canceled.
+func waitForKubernetesJob(ctx context.Context, jobs kubernetesJobGetter, name string) (*RunResult, error) {
+	for {
+		current, err := jobs.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("get job: %v", err)
+		}
+
+		if current.Status.Succeeded > 0 {
+			return &RunResult{ExitCode: 0}, nil
+		}
+		if current.Status.Failed > 0 {
+			return &RunResult{ExitCode: 1, StoppedReason: "job failed"}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func int32Ptr(i int32) *int32 { return &i }
@@ -1,38 +1,33 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
+	"github.com/icco/scheduler/httpx"
 	"github.com/pquerna/ffjson/ffjson"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/robfig/cron"
-	"gopkg.in/unrolled/render.v1"
 	"gopkg.in/unrolled/secure.v1"
 )
 
-var (
-	// Renderer is a renderer for all occasions. These are our preferred default options.
-	Renderer = render.New(render.Options{
-		Directory:                 "views",
-		Extensions:                []string{".tmpl", ".html"}, // Specify extensions to load for templates.
-		Charset:                   "UTF-8",                    // Sets encoding for content-types. Default is "UTF-8".
-		IndentJSON:                false,                      // Don't output human readable JSON.
-		IndentXML:                 true,                       // Output human readable XML.
-		RequirePartials:           true,                       // Return an error if a template is missing a partial used in a layout.
-		DisableHTTPErrorRendering: false,                      // Enables automatic rendering of http.StatusInternalServerError when an error occurs.
-	})
+// shutdownTimeout bounds how long we wait for in-flight HTTP requests to
+// drain on SIGTERM/SIGINT before giving up and exiting.
+const shutdownTimeout = 15 * time.Second
 
+var (
 	// SecureMiddlewareOptions is a set of defaults for securing web apps.
 	// SSLRedirect is handeled by a different middleware because it does not
 	// support whitelisting paths.
@@ -48,8 +43,38 @@ var (
 		BrowserXssFilter:     true,
 		IsDevelopment:        os.Getenv("FLM_ENV") == "local",
 	}
+
+	// sslExemptPatterns is the set of glob patterns (matched with path.Match
+	// against the request path) that SSLMiddleware lets through over plain
+	// HTTP. Defaults to the liveness check; more can be added via the
+	// SSL_EXEMPT_PATTERNS env var (comma-separated) so things like /metrics
+	// or future readiness probes can opt out too.
+	sslExemptPatterns = parseSSLExemptPatterns(os.Getenv("SSL_EXEMPT_PATTERNS"))
 )
 
+// parseSSLExemptPatterns builds the SSL exemption list from a comma-separated
+// env var, always including the liveness check.
+func parseSSLExemptPatterns(raw string) []string {
+	patterns := []string{"/_healthcheck.json"}
+	for _, p := range strings.Split(raw, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// isSSLExempt reports whether reqPath matches one of sslExemptPatterns.
+func isSSLExempt(reqPath string) bool {
+	for _, pattern := range sslExemptPatterns {
+		if ok, err := path.Match(pattern, reqPath); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
 func main() {
 	port := "8080"
 	if fromEnv := os.Getenv("PORT"); fromEnv != "" {
@@ -57,6 +82,29 @@ func main() {
 	}
 	log.Printf("Starting up on %s", port)
 
+	cf, err := GetConfig()
+	if err != nil {
+		log.Fatalf("Error getting config: %+v", err)
+	}
+
+	sched := NewScheduler(cf)
+	sched.Start()
+	readiness := NewReadinessCheck(sched)
+
+	// Reload the schedule on SIGHUP without dropping in-flight jobs.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Printf("Received SIGHUP, reloading config")
+			if cf, err := GetConfig(); err != nil {
+				log.Printf("Error reloading config: %+v", err)
+			} else {
+				sched.Reload(cf)
+			}
+		}
+	}()
+
 	secureMiddleware := secure.New(SecureMiddlewareOptions)
 
 	r := chi.NewRouter()
@@ -69,18 +117,165 @@ func main() {
 
 	// Metrics
 	r.Get("/_healthcheck.json", healthCheckHandler)
+	r.Get("/_readycheck.json", readyCheckHandler(readiness))
 	r.Mount("/metrics", promhttp.Handler())
 
 	// Web app
 	r.Get("/", homeHandler)
 	r.Get("/cron", cronHandler)
+	r.Get("/jobs", jobsHandler(sched))
+	r.Get("/jobs/{name}/runs", jobRunsHandler(sched))
+	r.Get("/jobs/{name}/schedule.ics", jobScheduleICSHandler(sched))
+	r.With(bearerAuthMiddleware).Post("/reload", reloadHandler(sched))
+	r.With(bearerAuthMiddleware).Post("/jobs/{name}/run", jobRunHandler(sched))
+	r.With(bearerAuthMiddleware).Post("/jobs/{name}/dry-run", jobDryRunHandler(sched))
+
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, syscall.SIGTERM, syscall.SIGINT)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-term
+		log.Printf("Received shutdown signal, draining connections and stopping the scheduler")
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("Error shutting down server: %+v", err)
+		}
+		sched.Stop()
+	}()
 
 	log.Printf("Server listening on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("Error serving: %+v", err)
+	}
+
+	// ListenAndServe returns as soon as Shutdown begins; wait for the drain
+	// and the scheduler stop to finish before exiting.
+	<-stopped
+}
+
+// jobsHandler returns the current status of every scheduled job as JSON:
+// last-run time, next-run time, last error (if any), and run duration.
+func jobsHandler(s *Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		js, err := ffjson.Marshal(s.Status())
+		if err != nil {
+			log.Printf("Error marshaling job status: %+v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+	}
+}
+
+// jobRunsHandler returns the recent invocation history for a single job.
+func jobRunsHandler(s *Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		runs, ok := s.Runs(name)
+		if !ok {
+			http.Error(w, "No such job", http.StatusNotFound)
+			return
+		}
+
+		js, err := ffjson.Marshal(runs)
+		if err != nil {
+			log.Printf("Error marshaling runs for %q: %+v", name, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+	}
+}
+
+// jobRunHandler triggers a job immediately and returns the run ID so its
+// result can be looked up later via /jobs/{name}/runs.
+func jobRunHandler(s *Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		runID, err := s.TriggerRun(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		js, err := ffjson.Marshal(map[string]string{"run_id": runID})
+		if err != nil {
+			log.Printf("Error marshaling run ID: %+v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+	}
+}
+
+// jobDryRunHandler renders the resolved ECS task definition for a job
+// without submitting anything to AWS.
+func jobDryRunHandler(s *Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		input, err := s.DryRun(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		js, err := ffjson.Marshal(input)
+		if err != nil {
+			log.Printf("Error marshaling dry-run for %q: %+v", name, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+	}
+}
+
+// jobScheduleICSHandler emits an iCalendar feed of a job's upcoming runs.
+func jobScheduleICSHandler(s *Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		times, err := s.UpcomingRuns(name, icsOccurrences)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Write([]byte(renderICS(name, times)))
+	}
+}
+
+// reloadHandler rebuilds the schedule from the config file atomically,
+// without needing to restart the process or send SIGHUP.
+func reloadHandler(s *Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cf, err := GetConfig()
+		if err != nil {
+			log.Printf("Error reloading config: %+v", err)
+			http.Error(w, "Bad config file", http.StatusInternalServerError)
+			return
+		}
+
+		s.Reload(cf)
+		w.Write([]byte(`"ok."`))
+	}
 }
 
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	Renderer.JSON(w, http.StatusOK, map[string]string{
+	c := httpx.New(w, r)
+	c.JSON(http.StatusOK, map[string]string{
 		"healthy":  "true",
 		"revision": os.Getenv("GIT_REVISION"),
 		"tag":      os.Getenv("GIT_TAG"),
@@ -88,12 +283,27 @@ func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// SSLMiddleware redirects for all paths besides /_healthcheck.json. This is a
-// slight modification of the code in
+// readyCheckHandler runs every registered readiness Checker and reports 200
+// only if they all pass, 503 otherwise.
+func readyCheckHandler(h *HealthCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := httpx.New(w, r)
+
+		resp := h.Run(r.Context())
+		status := http.StatusOK
+		if !resp.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, resp)
+	}
+}
+
+// SSLMiddleware redirects to HTTPS for every path except those matching
+// sslExemptPatterns. This is a slight modification of the code in
 // https://github.com/unrolled/secure/blob/v1/secure.go
 func SSLMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/_healthcheck.json" {
+		if !isSSLExempt(r.URL.Path) {
 			ssl := strings.EqualFold(r.URL.Scheme, "https") || r.TLS != nil
 			if !ssl {
 				for k, v := range SecureMiddlewareOptions.SSLProxyHeaders {
@@ -109,7 +319,10 @@ func SSLMiddleware(next http.Handler) http.Handler {
 				url.Scheme = "https"
 				url.Host = r.Host
 
-				http.Redirect(w, r, url.String(), http.StatusMovedPermanently)
+				c := httpx.New(w, r)
+				if err := c.Redirect(http.StatusMovedPermanently, url.String()); err != nil {
+					c.Error(http.StatusBadRequest, err)
+				}
 				return
 			}
 		}
@@ -119,29 +332,23 @@ func SSLMiddleware(next http.Handler) http.Handler {
 }
 
 func homeHandler(w http.ResponseWriter, r *http.Request) {
-	cronFile, err := GetConfig()
-	if err != nil {
-		log.Printf("Error getting config: %+v", err)
-		http.Error(w, "Bad config file", http.StatusInternalServerError)
-		return
-	}
+	c := httpx.New(w, r)
 
-	js, err := ffjson.Marshal(cronFile)
+	cronFile, err := GetConfig()
 	if err != nil {
-		log.Printf("Error marshaling: %+v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.Error(http.StatusInternalServerError, fmt.Errorf("bad config file: %v", err))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(js)
+	c.JSON(http.StatusOK, cronFile)
 }
 
 func cronHandler(w http.ResponseWriter, r *http.Request) {
+	c := httpx.New(w, r)
+
 	cf, err := GetConfig()
 	if err != nil {
-		log.Printf("Error getting config: %+v", err)
-		http.Error(w, "Bad config file", http.StatusInternalServerError)
+		c.Error(http.StatusInternalServerError, fmt.Errorf("bad config file: %v", err))
 		return
 	}
 
@@ -154,11 +361,29 @@ func cronHandler(w http.ResponseWriter, r *http.Request) {
 		log.Printf("%+v - %+v", j, n)
 	}
 
-	w.Write([]byte(`"ok."`))
+	c.JSON(http.StatusOK, "ok.")
 }
 
 type ConfigFile struct {
 	Jobs []Job `json:"jobs"`
+
+	// Cluster, LaunchType, Subnets, and SecurityGroups describe where
+	// ECSExecutor.Run submits ECS tasks. Cluster and LaunchType fall back to
+	// the ECS_CLUSTER and ECS_LAUNCH_TYPE environment variables (see
+	// ecsSetting) so a deploy can configure them without touching the config
+	// file; Subnets and SecurityGroups are read from the config file only.
+	Cluster        string   `json:"cluster"`
+	LaunchType     string   `json:"launch_type"`
+	Subnets        []string `json:"subnets"`
+	SecurityGroups []string `json:"security_groups"`
+
+	// KubernetesNamespace is the namespace the Kubernetes backend creates
+	// Jobs in, falling back to the KUBERNETES_NAMESPACE env var.
+	KubernetesNamespace string `json:"kubernetes_namespace"`
+
+	// DefaultBackend is which Executor runs a Job that doesn't set its own
+	// Backend. Defaults to BackendECS.
+	DefaultBackend string `json:"default_backend"`
 }
 
 type Job struct {
@@ -168,6 +393,10 @@ type Job struct {
 	Image       string            `json:"image"`
 	Command     []string          `json:"command"`
 	Environment map[string]string `json:"environment"`
+
+	// Backend picks which Executor runs this job (see the Backend*
+	// constants in executor.go). Empty means "use ConfigFile.DefaultBackend".
+	Backend string `json:"backend"`
 }
 
 func (j *Job) Next(t time.Time) (time.Time, error) {
@@ -185,53 +414,6 @@ func (j *Job) Name() *string {
 	return aws.String(fmt.Sprintf("scheduled-%s", name))
 }
 
-// Run takes the docker image and the command, builds a task definition,
-// submits it to ECS, and runs the task.
-func (j *Job) Run() {
-	svc := ecs.New(session.New())
-
-	containerDef := &ecs.ContainerDefinition{
-		Essential:         aws.Bool(true),
-		Image:             aws.String(j.Image),
-		MemoryReservation: aws.Int64(1024),
-		Name:              j.Name(),
-	}
-
-	if len(j.Command) > 0 {
-		cmd := []*string{}
-		for _, i := range j.Command {
-			cmd = append(cmd, aws.String(i))
-		}
-
-		containerDef.Command = cmd
-	}
-
-	if len(j.Environment) > 0 {
-		pairs := []*ecs.KeyValuePair{}
-		for k, v := range j.Environment {
-			pairs = append(pairs, &ecs.KeyValuePair{
-				Name:  aws.String(k),
-				Value: aws.String(v),
-			})
-		}
-		containerDef.Environment = pairs
-	}
-
-	input := &ecs.RegisterTaskDefinitionInput{
-		ContainerDefinitions: []*ecs.ContainerDefinition{containerDef},
-		Family:               j.Name(),
-		TaskRoleArn:          aws.String(""),
-	}
-
-	result, err := svc.RegisterTaskDefinition(input)
-	if err != nil {
-		log.Printf("Task Def Error: %+v", err.Error())
-		return
-	}
-
-	log.Printf("%+v", result)
-}
-
 func GetConfig() (ConfigFile, error) {
 	filename := os.Getenv("SCHEDULER_CONFIG")
 	if filename == "" {
@@ -246,7 +428,9 @@ func GetConfig() (ConfigFile, error) {
 	log.Printf("%+s", data)
 
 	var cf ConfigFile
-	err = ffjson.Unmarshal(data, &cf)
+	if err := ffjson.Unmarshal(data, &cf); err != nil {
+		return ConfigFile{}, err
+	}
 
 	log.Printf("%+v", cf)
 	return cf, nil
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	jobRunsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_job_runs_total",
+		Help: "Total number of job runs, labeled by job name and result.",
+	}, []string{"job", "status"})
+
+	jobRunDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scheduler_job_run_duration_seconds",
+		Help:    "How long each job run took, labeled by job name.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"job"})
+)
+
+func init() {
+	prometheus.MustRegister(jobRunsTotal, jobRunDuration)
+}
+
+// observeJobRun records a completed job run against the Prometheus metrics
+// above.
+func observeJobRun(name string, success bool, d time.Duration) {
+	status := "failure"
+	if success {
+		status = "success"
+	}
+	jobRunsTotal.WithLabelValues(name, status).Inc()
+	jobRunDuration.WithLabelValues(name).Observe(d.Seconds())
+}
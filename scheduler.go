@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/robfig/cron"
+)
+
+// maxRunHistory caps how many past invocations of a job we keep in memory,
+// so a frequently-scheduled job can't grow this without bound.
+const maxRunHistory = 20
+
+// maxStoredLogBytes caps how much of a single run's captured logs we keep in
+// memory and return from the API, so one chatty job can't blow up run
+// history or the response body.
+const maxStoredLogBytes = 64 * 1024
+
+// defaultHeartbeatInterval is the interval readiness checks compare against
+// when no job has a valid cron rule; the heartbeat then ticks every half of
+// it.
+const defaultHeartbeatInterval = time.Minute
+
+// Run is a single recorded invocation of a job.
+type Run struct {
+	ID            string        `json:"run_id"`
+	StartedAt     time.Time     `json:"started_at"`
+	FinishedAt    time.Time     `json:"finished_at"`
+	Duration      time.Duration `json:"duration"`
+	ExitCode      int64         `json:"exit_code"`
+	StoppedReason string        `json:"stopped_reason,omitempty"`
+	Error         string        `json:"error,omitempty"`
+	Logs          string        `json:"logs,omitempty"`
+}
+
+// JobStatus is the last known state of a single job's execution history, as
+// surfaced through the /jobs JSON status endpoint. The home page serves the
+// config file and does not include it.
+type JobStatus struct {
+	Name      string    `json:"name"`
+	NextRun   time.Time `json:"next_run"`
+	LastRun   time.Time `json:"last_run,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+	ExitCode  int64     `json:"exit_code"`
+	Running   bool      `json:"running"`
+	Duration  string    `json:"duration,omitempty"`
+}
+
+// Scheduler owns a cron.Cron instance and the bookkeeping needed to report on
+// job runs. It can be rebuilt in place via Reload, which is what makes SIGHUP
+// and the /reload endpoint safe to call while jobs are in flight.
+type Scheduler struct {
+	mu               sync.Mutex
+	cron             *cron.Cron
+	cf               ConfigFile
+	executors        map[string]Executor
+	running          map[string]bool
+	runs             map[string][]Run
+	lastTick         time.Time
+	shortestInterval time.Duration
+
+	// jobCtx is passed to every Executor.Run and canceled by Stop, so a
+	// graceful shutdown interrupts an in-flight ECS/Kubernetes poll loop
+	// instead of only stopping future cron fires.
+	jobCtx    context.Context
+	jobCancel context.CancelFunc
+}
+
+// NewScheduler builds a Scheduler from a ConfigFile but does not start it.
+func NewScheduler(cf ConfigFile) *Scheduler {
+	s := &Scheduler{
+		running: map[string]bool{},
+		runs:    map[string][]Run{},
+	}
+	s.jobCtx, s.jobCancel = context.WithCancel(context.Background())
+	s.build(cf)
+	return s
+}
+
+// build installs a fresh cron.Cron and executor set for the given config.
+// Callers must hold s.mu.
+func (s *Scheduler) build(cf ConfigFile) {
+	c := cron.New()
+	for _, job := range cf.Jobs {
+		j := job
+		if err := c.AddFunc(j.CronRule, func() { s.runJob(j, newRunID(j.RawName)) }); err != nil {
+			log.Printf("Error scheduling job %q: %+v", j.RawName, err)
+			continue
+		}
+	}
+
+	s.shortestInterval = shortestCronInterval(cf.Jobs)
+	heartbeat := fmt.Sprintf("@every %s", s.shortestInterval/2)
+	if err := c.AddFunc(heartbeat, s.recordTick); err != nil {
+		log.Printf("Error scheduling heartbeat: %+v", err)
+	}
+
+	s.cf = cf
+	s.cron = c
+	s.executors = NewExecutors(cf)
+	s.lastTick = time.Now()
+}
+
+// shortestCronInterval estimates the shortest gap between consecutive runs
+// across every job, by comparing each job's next two occurrences. Jobs with
+// an invalid cron rule are skipped; if none are valid, it falls back to
+// defaultHeartbeatInterval.
+func shortestCronInterval(jobs []Job) time.Duration {
+	shortest := defaultHeartbeatInterval
+	found := false
+
+	now := time.Now()
+	for _, j := range jobs {
+		sched, err := cron.Parse(j.CronRule)
+		if err != nil {
+			continue
+		}
+
+		first := sched.Next(now)
+		second := sched.Next(first)
+		interval := second.Sub(first)
+
+		if !found || interval < shortest {
+			shortest = interval
+			found = true
+		}
+	}
+
+	return shortest
+}
+
+// recordTick marks that the scheduler's cron loop is still alive.
+func (s *Scheduler) recordTick() {
+	s.mu.Lock()
+	s.lastTick = time.Now()
+	s.mu.Unlock()
+}
+
+// Heartbeat returns when the scheduler last ticked and the shortest interval
+// between consecutive runs of any one configured job, for readiness checks to
+// compare against.
+func (s *Scheduler) Heartbeat() (lastTick time.Time, shortestInterval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastTick, s.shortestInterval
+}
+
+// Executors returns the Executor backends currently configured, keyed by
+// backend name, so readiness checks can probe the ones actually in use.
+func (s *Scheduler) Executors() map[string]Executor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]Executor, len(s.executors))
+	for k, v := range s.executors {
+		out[k] = v
+	}
+	return out
+}
+
+// UsesBackend reports whether any currently configured job resolves to the
+// given backend, so readiness checks can skip probing backends nothing is
+// scheduled against. An empty job list is treated as using BackendECS, its
+// long-standing default.
+func (s *Scheduler) UsesBackend(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.cf.Jobs) == 0 {
+		return name == BackendECS
+	}
+	for _, j := range s.cf.Jobs {
+		if backendFor(s.cf, j) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Start begins running scheduled jobs in the background.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cron.Start()
+}
+
+// Stop halts the cron scheduler and cancels the context passed to any
+// in-flight Executor.Run, so an ECS/Kubernetes poll loop unwinds instead of
+// being left running past process shutdown. It does not wait for in-flight
+// jobs to finish unwinding.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cron.Stop()
+	s.jobCancel()
+}
+
+// Reload rebuilds the schedule from a new ConfigFile, stopping the old cron
+// and starting the new one. It is safe to call while jobs are running; a job
+// already in flight is allowed to finish under its old definition.
+func (s *Scheduler) Reload(cf ConfigFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cron.Stop()
+	s.build(cf)
+	s.cron.Start()
+
+	log.Printf("Reloaded config: %d jobs scheduled", len(cf.Jobs))
+}
+
+// newRunID generates an identifier for a single job invocation, unique
+// enough to correlate a trigger response with its eventual history entry.
+func newRunID(name string) string {
+	return fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+}
+
+// runJob executes a single job, guarding against overlapping runs of the same
+// job and recording the result for the status and run-history endpoints.
+func (s *Scheduler) runJob(j Job, runID string) {
+	name := j.RawName
+
+	s.mu.Lock()
+	if s.running[name] {
+		s.mu.Unlock()
+		log.Printf("Skipping %q: previous run still active", name)
+		return
+	}
+	s.running[name] = true
+	cf := s.cf
+	executor, err := resolveExecutor(s.executors, cf, j)
+	ctx := s.jobCtx
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.running[name] = false
+		s.mu.Unlock()
+	}()
+
+	if err != nil {
+		log.Printf("Job %q failed: %+v", name, err)
+		s.mu.Lock()
+		s.runs[name] = appendRun(s.runs[name], Run{ID: runID, StartedAt: time.Now(), Error: err.Error()})
+		s.mu.Unlock()
+		return
+	}
+
+	start := time.Now()
+	result, err := executor.Run(ctx, j)
+	finished := time.Now()
+
+	run := Run{
+		ID:         runID,
+		StartedAt:  start,
+		FinishedAt: finished,
+		Duration:   finished.Sub(start),
+	}
+	if result != nil {
+		run.ExitCode = result.ExitCode
+		run.StoppedReason = result.StoppedReason
+		run.Logs = truncateLogs(result.Logs)
+	}
+
+	success := err == nil && run.ExitCode == 0
+	if err != nil {
+		run.Error = err.Error()
+		log.Printf("Job %q failed: %+v", name, err)
+	}
+	observeJobRun(name, success, run.Duration)
+
+	s.mu.Lock()
+	s.runs[name] = appendRun(s.runs[name], run)
+	s.mu.Unlock()
+}
+
+// truncateLogs caps logs at maxStoredLogBytes, keeping the tail since that's
+// almost always where the interesting failure output is.
+func truncateLogs(logs string) string {
+	if len(logs) <= maxStoredLogBytes {
+		return logs
+	}
+	return "...(truncated)...\n" + logs[len(logs)-maxStoredLogBytes:]
+}
+
+// appendRun appends run to history, trimming to maxRunHistory entries.
+func appendRun(history []Run, run Run) []Run {
+	history = append(history, run)
+	if len(history) > maxRunHistory {
+		history = history[len(history)-maxRunHistory:]
+	}
+	return history
+}
+
+// Status returns the current status of every scheduled job, including its
+// next scheduled run time.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	out := make([]JobStatus, 0, len(s.cf.Jobs))
+	for _, j := range s.cf.Jobs {
+		st := JobStatus{Name: j.RawName, Running: s.running[j.RawName]}
+		if history := s.runs[j.RawName]; len(history) > 0 {
+			last := history[len(history)-1]
+			st.LastRun = last.StartedAt
+			st.ExitCode = last.ExitCode
+			st.Duration = last.Duration.String()
+			st.LastError = last.Error
+		}
+		if next, err := j.Next(now); err == nil {
+			st.NextRun = next
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// Runs returns the recent invocation history for a single job, most recent
+// last. The second return value is false if the job is not scheduled.
+func (s *Scheduler) Runs(name string) ([]Run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.cf.Jobs {
+		if j.RawName == name {
+			return s.runs[name], true
+		}
+	}
+	return nil, false
+}
+
+// TriggerRun runs a job immediately, outside of its normal schedule, and
+// returns a run ID the caller can use to look the result up later via Runs.
+// It respects the same per-job concurrency guard as scheduled runs.
+func (s *Scheduler) TriggerRun(name string) (string, error) {
+	s.mu.Lock()
+	var job *Job
+	for i := range s.cf.Jobs {
+		if s.cf.Jobs[i].RawName == name {
+			job = &s.cf.Jobs[i]
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if job == nil {
+		return "", fmt.Errorf("no such job %q", name)
+	}
+
+	runID := newRunID(name)
+	j := *job
+	go s.runJob(j, runID)
+	return runID, nil
+}
+
+// DryRun resolves a job's ECS task definition without submitting it to AWS.
+// It only supports jobs whose resolved backend is BackendECS.
+func (s *Scheduler) DryRun(name string) (*ecs.RegisterTaskDefinitionInput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.cf.Jobs {
+		if j.RawName != name {
+			continue
+		}
+
+		ex, ok := s.executors[backendFor(s.cf, j)].(*ECSExecutor)
+		if !ok {
+			return nil, fmt.Errorf("dry-run is only supported for the %q backend", BackendECS)
+		}
+		return ex.buildTaskDefinitionInput(j), nil
+	}
+	return nil, fmt.Errorf("no such job %q", name)
+}
+
+// UpcomingRuns projects the next n scheduled times for a job, for rendering
+// as an iCalendar feed.
+func (s *Scheduler) UpcomingRuns(name string, n int) ([]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.cf.Jobs {
+		if j.RawName != name {
+			continue
+		}
+
+		sched, err := cron.Parse(j.CronRule)
+		if err != nil {
+			return nil, err
+		}
+
+		times := make([]time.Time, 0, n)
+		t := time.Now()
+		for i := 0; i < n; i++ {
+			t = sched.Next(t)
+			times = append(times, t)
+		}
+		return times, nil
+	}
+	return nil, fmt.Errorf("no such job %q", name)
+}